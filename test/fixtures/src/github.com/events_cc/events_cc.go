@@ -17,11 +17,14 @@ limitations under the License.
 package main
 
 import (
-	"errors"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
 )
 
 // EventSender example simple Chaincode implementation
@@ -29,74 +32,353 @@ type EventSender struct {
 }
 
 // Init function
-func (t *EventSender) Init(stub shim.ChaincodeStubInterface) ([]byte, error) {
+func (t *EventSender) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	err := stub.PutState("noevents", []byte("0"))
 	if err != nil {
-		return nil, err
+		return shim.Error(err.Error())
 	}
-	return nil, nil
+	return shim.Success(nil)
 }
 
-// Invoke function
-func (t *EventSender) invoke(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	if len(args) != 2 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 2")
+// buildTablePayload turns a CSV header row and a CSV data row into a
+// row-oriented JSON object, e.g. "a,b" / "1,2" -> {"a":"1","b":"2"}.
+func buildTablePayload(header, row string) map[string]string {
+	cols := strings.Split(header, ",")
+	vals := strings.Split(row, ",")
+	table := make(map[string]string, len(cols))
+	for i, col := range cols {
+		if i < len(vals) {
+			table[col] = vals[i]
+		}
+	}
+	return table
+}
+
+// invoke function. args[1] selects the payload type ("string", "json" or
+// "table") so that listeners receive a stable, versioned envelope instead
+// of an ad-hoc string.
+func (t *EventSender) invoke(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 3 {
+		return shim.Error("Incorrect number of arguments. Expecting at least 3")
 	}
+	payloadType := args[1]
+
 	b, err := stub.GetState("noevents")
 	if err != nil {
-		return nil, errors.New("Failed to get state")
+		return shim.Error("Failed to get state")
 	}
 	noevts, _ := strconv.Atoi(string(b))
 
-	tosend := "Event " + string(b) + args[1]
+	var data interface{}
+	switch payloadType {
+	case "string":
+		if len(args) != 3 {
+			return shim.Error("Incorrect number of arguments for payloadType \"string\". Expecting 3")
+		}
+		data = args[2]
+	case "json":
+		if len(args) != 3 {
+			return shim.Error("Incorrect number of arguments for payloadType \"json\". Expecting 3")
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(args[2]), &parsed); err != nil {
+			return shim.Error(fmt.Sprintf("Invalid json payload: %s", err))
+		}
+		data = parsed
+	case "table":
+		if len(args) != 4 {
+			return shim.Error("Incorrect number of arguments for payloadType \"table\". Expecting 4 (header row, data row)")
+		}
+		data = buildTablePayload(args[2], args[3])
+	default:
+		return shim.Error(fmt.Sprintf("Unknown payloadType %q. Expecting \"string\", \"json\" or \"table\"", payloadType))
+	}
+
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	envelope := map[string]interface{}{
+		"schema":    "v1",
+		"type":      payloadType,
+		"seq":       noevts,
+		"txID":      stub.GetTxID(),
+		"timestamp": timestamp,
+		"data":      data,
+	}
+	tosend, err := json.Marshal(envelope)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 	eventName := "evtsender" + args[0]
 
 	err = stub.PutState("noevents", []byte(strconv.Itoa(noevts+1)))
 	if err != nil {
-		return nil, err
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent(eventName, tosend)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// invokeCC invokes another chaincode (optionally on a different channel) and
+// forwards the result as an event so that SDK-side listeners can observe
+// cross-chaincode invocations end-to-end.
+func (t *EventSender) invokeCC(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 3 or 4")
+	}
+
+	targetCC := args[1]
+	ccArgs := [][]byte{[]byte(args[2])}
+	channel := ""
+	if len(args) == 4 {
+		channel = args[3]
+	}
+
+	response := stub.InvokeChaincode(targetCC, ccArgs, channel)
+	if response.Status != shim.OK {
+		return shim.Error(fmt.Sprintf("Failed to invoke chaincode %s on channel %s: %s", targetCC, channel, response.Message))
+	}
+
+	envelope := map[string]interface{}{
+		"target":  targetCC,
+		"status":  response.Status,
+		"payload": base64.StdEncoding.EncodeToString(response.Payload),
+	}
+	tosend, err := json.Marshal(envelope)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	eventName := "evtsender_cc_" + targetCC
+
+	err = stub.SetEvent(eventName, tosend)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(response.Payload)
+}
+
+// batchEvent is one logical event within a batch submitted to invokeBatch.
+type batchEvent struct {
+	Name    string `json:"name"`
+	Payload string `json:"payload"`
+}
+
+// invokeBatch aggregates N logical events into a single composite event, to
+// work around Fabric only allowing one event per transaction via SetEvent.
+func (t *EventSender) invokeBatch(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+
+	var batch []batchEvent
+	if err := json.Unmarshal([]byte(args[1]), &batch); err != nil {
+		return shim.Error(fmt.Sprintf("Invalid batch payload: %s", err))
+	}
+	if len(batch) == 0 {
+		return shim.Error("Batch must contain at least one event")
+	}
+
+	b, err := stub.GetState("noevents")
+	if err != nil {
+		return shim.Error("Failed to get state")
+	}
+	noevts, _ := strconv.Atoi(string(b))
+
+	events := make([]map[string]interface{}, len(batch))
+	for i, e := range batch {
+		events[i] = map[string]interface{}{
+			"name":    e.Name,
+			"payload": e.Payload,
+			"seq":     noevts + i,
+		}
+	}
+
+	composite := map[string]interface{}{
+		"count":  len(batch),
+		"events": events,
+	}
+	tosend, err := json.Marshal(composite)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutState("noevents", []byte(strconv.Itoa(noevts+len(batch))))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.SetEvent("evtsender_batch", tosend)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// invokePrivate stores the event payload in a private data collection
+// instead of public state, and emits an event carrying only a hash of that
+// payload (via GetPrivateDataHash) so the sensitive body never goes on-chain.
+// The "noevents" key holds the numeric counter, same as the public invoke,
+// while the sensitive body is kept under its own "payload" key.
+func (t *EventSender) invokePrivate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+	collection := args[1]
+
+	b, err := stub.GetPrivateData(collection, "noevents")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to get private state: %s", err))
+	}
+	noevts, _ := strconv.Atoi(string(b))
+
+	body := map[string]interface{}{
+		"seq":  noevts,
+		"data": args[2],
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutPrivateData(collection, "payload", payload)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutPrivateData(collection, "noevents", []byte(strconv.Itoa(noevts+1)))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	hash, err := stub.GetPrivateDataHash(collection, "payload")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to get private data hash: %s", err))
+	}
+
+	envelope := map[string]interface{}{
+		"collection": collection,
+		"seq":        noevts,
+		"hash":       base64.StdEncoding.EncodeToString(hash),
+	}
+	tosend, err := json.Marshal(envelope)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
+	eventName := "evtsender_private_" + collection
 
-	err = stub.SetEvent(eventName, []byte(tosend))
+	err = stub.SetEvent(eventName, tosend)
 	if err != nil {
-		return nil, err
+		return shim.Error(err.Error())
 	}
-	return nil, nil
+	return shim.Success(nil)
 }
 
-// Clear State function
-func (t *EventSender) clear(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+// clear resets the event counter.
+func (t *EventSender) clear(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	err := stub.PutState("noevents", []byte("0"))
 	if err != nil {
-		return nil, err
+		return shim.Error(err.Error())
 	}
-	return nil, nil
+	return shim.Success(nil)
 }
 
-// Query function
-func (t *EventSender) query(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+// query returns the current event counter.
+func (t *EventSender) query(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	b, err := stub.GetState("noevents")
 	if err != nil {
-		return nil, errors.New("Failed to get state")
+		return shim.Error("Failed to get state")
 	}
-	return b, nil
+	return shim.Success(b)
 }
 
-func (t *EventSender) Invoke(stub shim.ChaincodeStubInterface) ([]byte, error) {
-	function, args := stub.GetFunctionAndParameters()
+// delete removes the event counter from state and emits a deletion event.
+func (t *EventSender) delete(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	err := stub.DelState("noevents")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to delete state: %s", err))
+	}
+
+	tosend := fmt.Sprintf(`{"txID":"%s"}`, stub.GetTxID())
+	err = stub.SetEvent("evtsender_delete", []byte(tosend))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// history returns every past value of the event counter as a JSON array,
+// each entry carrying its txID and timestamp.
+func (t *EventSender) history(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	iter, err := stub.GetHistoryForKey("noevents")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to get history for noevents: %s", err))
+	}
+	defer iter.Close()
 
-	if function != "invoke" {
-		return nil, errors.New("Unknown function call")
+	history := []map[string]interface{}{}
+	for iter.HasNext() {
+		mod, err := iter.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		history = append(history, map[string]interface{}{
+			"txID":      mod.TxId,
+			"timestamp": mod.Timestamp,
+			"isDelete":  mod.IsDelete,
+			"value":     string(mod.Value),
+		})
 	}
 
-	if args[0] == "invoke" {
-		return t.invoke(stub, args)
-	} else if args[0] == "query" {
+	payload, err := json.Marshal(history)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(payload)
+}
+
+// Invoke function. Routes on function directly (invoke, query, clear,
+// delete, history); the "invoke" function additionally dispatches on
+// args[0] to select a sub-function (invoke, query, clear, invokeCC,
+// invokeBatch, invokePrivate).
+func (t *EventSender) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+
+	switch function {
+	case "invoke":
+		if len(args) == 0 {
+			return shim.Error("Expecting a sub-function name as args[0]")
+		}
+		if args[0] == "invoke" {
+			return t.invoke(stub, args)
+		} else if args[0] == "query" {
+			return t.query(stub, args)
+		} else if args[0] == "clear" {
+			return t.clear(stub, args)
+		} else if args[0] == "invokeCC" {
+			return t.invokeCC(stub, args)
+		} else if args[0] == "invokeBatch" {
+			return t.invokeBatch(stub, args)
+		} else if args[0] == "invokePrivate" {
+			return t.invokePrivate(stub, args)
+		}
+		return shim.Error("Invalid invoke function name. Expecting \"invoke\" \"query\" \"clear\" \"invokeCC\" \"invokeBatch\" \"invokePrivate\"")
+	case "query":
 		return t.query(stub, args)
-	} else if args[0] == "query" {
+	case "clear":
 		return t.clear(stub, args)
+	case "delete":
+		return t.delete(stub, args)
+	case "history":
+		return t.history(stub, args)
 	}
 
-	return nil, errors.New("Invalid invoke function name. Expecting \"invoke\" \"query\"")
+	return shim.Error("Unknown function call")
 }
 
 func main() {